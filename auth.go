@@ -0,0 +1,626 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthConfig controls JWT signing and token lifetimes. SigningMethod is
+// "HS256" (shared secret, the default) or "RS256" (asymmetric, so multiple
+// instances can verify tokens without sharing a symmetric secret).
+type AuthConfig struct {
+	SigningMethod jwt.SigningMethod
+	HMACSecret    []byte
+	RSAPrivateKey *rsa.PrivateKey
+	RSAPublicKey  *rsa.PublicKey
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+}
+
+// loadAuthConfig reads signing configuration from the environment:
+//
+//	AUTH_SIGNING_METHOD        "HS256" (default) or "RS256"
+//	AUTH_SECRET                HMAC secret for HS256 (falls back to SecretKey)
+//	AUTH_RSA_PRIVATE_KEY       PEM-encoded RSA private key for RS256
+//	AUTH_RSA_PUBLIC_KEY        PEM-encoded RSA public key for RS256
+//	AUTH_ACCESS_TTL_SECONDS    access token lifetime (default 900 = 15m)
+//	AUTH_REFRESH_TTL_SECONDS   refresh token lifetime (default 2592000 = 30d)
+func loadAuthConfig() AuthConfig {
+	cfg := AuthConfig{
+		SigningMethod: jwt.SigningMethodHS256,
+		HMACSecret:    []byte(SecretKey),
+		AccessTTL:     15 * time.Minute,
+		RefreshTTL:    30 * 24 * time.Hour,
+	}
+
+	if secret := os.Getenv("AUTH_SECRET"); secret != "" {
+		cfg.HMACSecret = []byte(secret)
+	}
+	if v := os.Getenv("AUTH_ACCESS_TTL_SECONDS"); v != "" {
+		if seconds, err := time.ParseDuration(v + "s"); err == nil {
+			cfg.AccessTTL = seconds
+		}
+	}
+	if v := os.Getenv("AUTH_REFRESH_TTL_SECONDS"); v != "" {
+		if seconds, err := time.ParseDuration(v + "s"); err == nil {
+			cfg.RefreshTTL = seconds
+		}
+	}
+
+	if os.Getenv("AUTH_SIGNING_METHOD") == "RS256" {
+		privPEM := os.Getenv("AUTH_RSA_PRIVATE_KEY")
+		pubPEM := os.Getenv("AUTH_RSA_PUBLIC_KEY")
+		privKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privPEM))
+		if err != nil {
+			log.Fatalf("invalid AUTH_RSA_PRIVATE_KEY: %v", err)
+		}
+		pubKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pubPEM))
+		if err != nil {
+			log.Fatalf("invalid AUTH_RSA_PUBLIC_KEY: %v", err)
+		}
+		cfg.SigningMethod = jwt.SigningMethodRS256
+		cfg.RSAPrivateKey = privKey
+		cfg.RSAPublicKey = pubKey
+	}
+
+	return cfg
+}
+
+func (cfg AuthConfig) signingKey() interface{} {
+	if cfg.SigningMethod == jwt.SigningMethodRS256 {
+		return cfg.RSAPrivateKey
+	}
+	return cfg.HMACSecret
+}
+
+func (cfg AuthConfig) verifyingKey() interface{} {
+	if cfg.SigningMethod == jwt.SigningMethodRS256 {
+		return cfg.RSAPublicKey
+	}
+	return cfg.HMACSecret
+}
+
+// Claims identifies the subject and whether the token is an access or
+// refresh token, since both are signed the same way but carry different
+// privileges.
+type Claims struct {
+	Username  string `json:"sub"`
+	TokenType string `json:"typ"` // "access" or "refresh"
+	jwt.RegisteredClaims
+}
+
+// User is a registered account. PasswordHash is bcrypt and never leaves
+// the store.
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// RefreshToken is the server-side record backing a token pair's shared jti
+// - an access token and the refresh token issued alongside it carry the
+// same jti, so revoking either one (e.g. via /api/auth/logout) revokes both,
+// independently of the JWTs' own expiration.
+type RefreshToken struct {
+	JTI       string    `json:"jti"`
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Revoked   bool      `json:"revoked"`
+}
+
+var ErrUserNotFound = fmt.Errorf("user not found")
+var ErrUserExists = fmt.Errorf("user already exists")
+var ErrInvalidCredentials = fmt.Errorf("invalid credentials")
+var ErrRefreshTokenNotFound = fmt.Errorf("refresh token not found")
+
+// UserStore persists accounts and the refresh-token/revocation state used
+// by the access+refresh token flow.
+type UserStore interface {
+	Register(ctx context.Context, username, password string) (*User, error)
+	Authenticate(ctx context.Context, username, password string) (*User, error)
+	GetByID(ctx context.Context, id string) (*User, error)
+
+	StoreRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshToken(ctx context.Context, jti string) (*RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, jti string) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// NewUserStore constructs the UserStore selected by backend ("memory"
+// (default) or "postgres"), mirroring NewSessionStore.
+func NewUserStore(ctx context.Context, backend StoreBackend, dsn string) (UserStore, error) {
+	switch backend {
+	case StoreBackendMemory, "":
+		return NewMemoryUserStore(), nil
+	case StoreBackendPostgres:
+		return NewPostgresUserStore(ctx, dsn)
+	default:
+		return nil, fmt.Errorf("unsupported user store backend: %s", backend)
+	}
+}
+
+// MemoryUserStore is the default, single-instance UserStore.
+type MemoryUserStore struct {
+	mu            sync.RWMutex
+	byUsername    map[string]*User
+	byID          map[string]*User
+	refreshTokens map[string]*RefreshToken
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		byUsername:    make(map[string]*User),
+		byID:          make(map[string]*User),
+		refreshTokens: make(map[string]*RefreshToken),
+	}
+}
+
+func (s *MemoryUserStore) Register(ctx context.Context, username, password string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byUsername[username]; exists {
+		return nil, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+	s.byUsername[username] = user
+	s.byID[user.ID] = user
+	return user, nil
+}
+
+func (s *MemoryUserStore) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	s.mu.RLock()
+	user, exists := s.byUsername[username]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) GetByID(ctx context.Context, id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, exists := s.byID[id]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) StoreRefreshToken(ctx context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshTokens[token.JTI] = token
+	return nil
+}
+
+func (s *MemoryUserStore) GetRefreshToken(ctx context.Context, jti string) (*RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, exists := s.refreshTokens[jti]
+	if !exists {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *MemoryUserStore) RevokeRefreshToken(ctx context.Context, jti string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, exists := s.refreshTokens[jti]
+	if !exists {
+		return ErrRefreshTokenNotFound
+	}
+	token.Revoked = true
+	return nil
+}
+
+func (s *MemoryUserStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, exists := s.refreshTokens[jti]
+	if !exists {
+		return true, nil
+	}
+	return token.Revoked, nil
+}
+
+// PostgresUserStore persists accounts and refresh tokens via pgx. Schema
+// (created out of band by migrations):
+//
+//	CREATE TABLE users (
+//	    id TEXT PRIMARY KEY,
+//	    username TEXT UNIQUE NOT NULL,
+//	    password_hash TEXT NOT NULL,
+//	    created_at TIMESTAMPTZ NOT NULL
+//	);
+//	CREATE TABLE refresh_tokens (
+//	    jti TEXT PRIMARY KEY,
+//	    user_id TEXT NOT NULL REFERENCES users(id),
+//	    expires_at TIMESTAMPTZ NOT NULL,
+//	    revoked BOOLEAN NOT NULL DEFAULT FALSE
+//	);
+type PostgresUserStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserStore(ctx context.Context, dsn string) (*PostgresUserStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	return &PostgresUserStore{pool: pool}, nil
+}
+
+func (s *PostgresUserStore) Register(ctx context.Context, username, password string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{ID: uuid.New().String(), Username: username, PasswordHash: string(hash), CreatedAt: time.Now()}
+	_, err = s.pool.Exec(ctx,
+		`INSERT INTO users (id, username, password_hash, created_at) VALUES ($1, $2, $3, $4)`,
+		user.ID, user.Username, user.PasswordHash, user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *PostgresUserStore) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	var user User
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, username, password_hash, created_at FROM users WHERE username = $1`, username,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+func (s *PostgresUserStore) GetByID(ctx context.Context, id string) (*User, error) {
+	var user User
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, username, password_hash, created_at FROM users WHERE id = $1`, id,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *PostgresUserStore) StoreRefreshToken(ctx context.Context, token *RefreshToken) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO refresh_tokens (jti, user_id, expires_at, revoked) VALUES ($1, $2, $3, $4)`,
+		token.JTI, token.UserID, token.ExpiresAt, token.Revoked)
+	return err
+}
+
+func (s *PostgresUserStore) GetRefreshToken(ctx context.Context, jti string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := s.pool.QueryRow(ctx,
+		`SELECT jti, user_id, expires_at, revoked FROM refresh_tokens WHERE jti = $1`, jti,
+	).Scan(&token.JTI, &token.UserID, &token.ExpiresAt, &token.Revoked)
+	if err == pgx.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *PostgresUserStore) RevokeRefreshToken(ctx context.Context, jti string) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE jti = $1`, jti)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (s *PostgresUserStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.pool.QueryRow(ctx, `SELECT revoked FROM refresh_tokens WHERE jti = $1`, jti).Scan(&revoked)
+	if err == pgx.ErrNoRows {
+		return true, nil
+	}
+	return revoked, err
+}
+
+// createAccessToken issues a short-lived token authorizing API/WebSocket
+// use, sharing jti with the refresh token issued alongside it so the pair
+// can be revoked together.
+func createAccessToken(username, jti string) (string, error) {
+	return signClaims(Claims{
+		Username:  username,
+		TokenType: "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(authConfig.AccessTTL)),
+		},
+	})
+}
+
+// createRefreshToken issues a long-lived token for jti, which the caller has
+// already recorded in userStore so it can be looked up and revoked later.
+func createRefreshToken(user *User, jti string, expiresAt time.Time) (string, error) {
+	return signClaims(Claims{
+		Username:  user.Username,
+		TokenType: "refresh",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+}
+
+func signClaims(claims Claims) (string, error) {
+	token := jwt.NewWithClaims(authConfig.SigningMethod, claims)
+	return token.SignedString(authConfig.signingKey())
+}
+
+// verifyToken validates a token's signature and expiry, rejects anything
+// that isn't an unrevoked access token, and returns the username it was
+// issued for. Callers that need to handle refresh tokens (refresh, logout)
+// should inspect parseClaims directly instead.
+func verifyToken(ctx context.Context, tokenString string) (string, error) {
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+	if claims.TokenType != "access" {
+		return "", fmt.Errorf("not an access token")
+	}
+
+	revoked, err := userStore.IsRevoked(ctx, claims.ID)
+	if err != nil || revoked {
+		return "", fmt.Errorf("token revoked")
+	}
+
+	return claims.Username, nil
+}
+
+func parseClaims(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return authConfig.verifyingKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type TokenPairResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// POST /api/auth/register
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		http.Error(w, "Username and password required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := userStore.Register(ctx, req.Username, req.Password)
+	if err == ErrUserExists {
+		http.Error(w, "Username already taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	loggerFromContext(ctx).Info("user registered", "username", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// POST /api/auth/login
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := userStore.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	loggerFromContext(ctx).Info("user logged in", "username", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// POST /api/auth/refresh
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	claims, err := parseClaims(req.RefreshToken)
+	if err != nil || claims.TokenType != "refresh" {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := userStore.IsRevoked(ctx, claims.ID)
+	if err != nil || revoked {
+		http.Error(w, "Refresh token revoked", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := userStore.GetByID(ctx, claims.Subject)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	// Rotate: the old refresh token is revoked as soon as it's used once.
+	userStore.RevokeRefreshToken(ctx, claims.ID)
+
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// POST /api/auth/logout
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	claims, err := parseClaims(req.RefreshToken)
+	if err != nil || claims.TokenType != "refresh" {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := userStore.RevokeRefreshToken(ctx, claims.ID); err != nil && err != ErrRefreshTokenNotFound {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func issueTokenPair(ctx context.Context, user *User) (*TokenPairResponse, error) {
+	jti := uuid.New().String()
+	expiresAt := time.Now().Add(authConfig.RefreshTTL)
+
+	if err := userStore.StoreRefreshToken(ctx, &RefreshToken{JTI: jti, UserID: user.ID, ExpiresAt: expiresAt}); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := createAccessToken(user.Username, jti)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := createRefreshToken(user, jti, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPairResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
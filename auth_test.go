@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// withTestAuth points the package globals verifyToken/issueTokenPair depend
+// on at a throwaway in-memory store and signing config, restoring the
+// previous globals afterwards so tests don't leak state into each other.
+func withTestAuth(t *testing.T) context.Context {
+	t.Helper()
+
+	prevUserStore, prevAuthConfig := userStore, authConfig
+	userStore = NewMemoryUserStore()
+	authConfig = AuthConfig{
+		SigningMethod: jwt.SigningMethodHS256,
+		HMACSecret:    []byte("test-secret"),
+		AccessTTL:     15 * time.Minute,
+		RefreshTTL:    30 * 24 * time.Hour,
+	}
+	t.Cleanup(func() {
+		userStore, authConfig = prevUserStore, prevAuthConfig
+	})
+
+	return context.Background()
+}
+
+func TestVerifyTokenAcceptsAccessToken(t *testing.T) {
+	ctx := withTestAuth(t)
+
+	user, err := userStore.Register(ctx, "alice", "hunter2")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		t.Fatalf("issueTokenPair: %v", err)
+	}
+
+	username, err := verifyToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("verifyToken(access): %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("got username %q, want %q", username, "alice")
+	}
+}
+
+func TestVerifyTokenRejectsRefreshToken(t *testing.T) {
+	ctx := withTestAuth(t)
+
+	user, err := userStore.Register(ctx, "bob", "hunter2")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		t.Fatalf("issueTokenPair: %v", err)
+	}
+
+	if _, err := verifyToken(ctx, tokens.RefreshToken); err == nil {
+		t.Fatal("verifyToken(refresh) succeeded, want rejection")
+	}
+}
+
+func TestVerifyTokenRejectsRevokedAccessToken(t *testing.T) {
+	ctx := withTestAuth(t)
+
+	user, err := userStore.Register(ctx, "carol", "hunter2")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	tokens, err := issueTokenPair(ctx, user)
+	if err != nil {
+		t.Fatalf("issueTokenPair: %v", err)
+	}
+
+	claims, err := parseClaims(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("parseClaims: %v", err)
+	}
+
+	// Revoking the refresh token's jti must also invalidate the access
+	// token issued alongside it, since the pair shares one jti.
+	if err := userStore.RevokeRefreshToken(ctx, claims.ID); err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+
+	if _, err := verifyToken(ctx, tokens.AccessToken); err == nil {
+		t.Fatal("verifyToken(access) succeeded after revocation, want rejection")
+	}
+}
+
+func TestVerifyTokenRejectsGarbage(t *testing.T) {
+	ctx := withTestAuth(t)
+
+	if _, err := verifyToken(ctx, "not-a-jwt"); err == nil {
+		t.Fatal("verifyToken(garbage) succeeded, want error")
+	}
+}
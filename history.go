@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CallEvent is an immutable record of a single session transition, kept
+// alongside the aggregate CallRecord so a detailed timeline can be
+// reconstructed later even though Session itself is mutated in place.
+type CallEvent struct {
+	SessionID string    `json:"sessionId"`
+	Type      string    `json:"type"` // "pending", "active", "declined", "cancelled", "disconnected"
+	Initiator string    `json:"initiator"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CallRecord is the aggregate row surfaced by the history query API.
+type CallRecord struct {
+	SessionID string     `json:"sessionId"`
+	Caller    string     `json:"caller"`
+	Target    string     `json:"target"`
+	Type      string     `json:"type"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	Duration  float64    `json:"durationSeconds,omitempty"`
+	EndReason string     `json:"endReason,omitempty"`
+}
+
+// HistoryStore persists the call history / event audit log. Like
+// SessionStore, it is pluggable between memory and Postgres.
+type HistoryStore interface {
+	RecordEvent(ctx context.Context, event *CallEvent) error
+	StartCall(ctx context.Context, record *CallRecord) error
+	EndCall(ctx context.Context, sessionID string, endedAt time.Time, reason string) error
+
+	GetHistory(ctx context.Context, username string, peer string, limit int, before time.Time) ([]*CallRecord, error)
+	GetEvents(ctx context.Context, sessionID string) ([]*CallEvent, error)
+	GetRecord(ctx context.Context, sessionID string) (*CallRecord, error)
+}
+
+// ErrCallRecordNotFound is returned by HistoryStore.GetRecord when sessionID
+// has no call record.
+var ErrCallRecordNotFound = fmt.Errorf("call record not found")
+
+// NewHistoryStore constructs the HistoryStore selected by backend ("memory"
+// (default) or "postgres"), mirroring NewSessionStore and NewUserStore.
+func NewHistoryStore(ctx context.Context, backend StoreBackend, dsn string) (HistoryStore, error) {
+	switch backend {
+	case StoreBackendMemory, "":
+		return NewMemoryHistoryStore(), nil
+	case StoreBackendPostgres:
+		return NewPostgresHistoryStore(ctx, dsn)
+	default:
+		return nil, fmt.Errorf("unsupported history store backend: %s", backend)
+	}
+}
+
+// MemoryHistoryStore is the default, single-instance HistoryStore.
+type MemoryHistoryStore struct {
+	mu      sync.RWMutex
+	events  map[string][]*CallEvent // sessionId -> events
+	records map[string]*CallRecord  // sessionId -> record
+	order   []string                // sessionIds in creation order, for pagination
+}
+
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{
+		events:  make(map[string][]*CallEvent),
+		records: make(map[string]*CallRecord),
+	}
+}
+
+func (s *MemoryHistoryStore) RecordEvent(ctx context.Context, event *CallEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[event.SessionID] = append(s.events[event.SessionID], event)
+	return nil
+}
+
+func (s *MemoryHistoryStore) StartCall(ctx context.Context, record *CallRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.SessionID] = record
+	s.order = append(s.order, record.SessionID)
+	return nil
+}
+
+func (s *MemoryHistoryStore) EndCall(ctx context.Context, sessionID string, endedAt time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.records[sessionID]
+	if !exists {
+		return fmt.Errorf("call record not found: %s", sessionID)
+	}
+	record.EndedAt = &endedAt
+	record.Duration = endedAt.Sub(record.StartedAt).Seconds()
+	record.EndReason = reason
+	return nil
+}
+
+func (s *MemoryHistoryStore) GetHistory(ctx context.Context, username, peer string, limit int, before time.Time) ([]*CallRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matching := make([]*CallRecord, 0)
+	for i := len(s.order) - 1; i >= 0; i-- {
+		record := s.records[s.order[i]]
+		if record == nil {
+			continue
+		}
+		if record.Caller != username && record.Target != username {
+			continue
+		}
+		if peer != "" && record.Caller != peer && record.Target != peer {
+			continue
+		}
+		if !before.IsZero() && !record.StartedAt.Before(before) {
+			continue
+		}
+		cp := *record
+		matching = append(matching, &cp)
+		if len(matching) >= limit {
+			break
+		}
+	}
+	return matching, nil
+}
+
+func (s *MemoryHistoryStore) GetEvents(ctx context.Context, sessionID string) ([]*CallEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.events[sessionID]
+	sorted := make([]*CallEvent, len(events))
+	copy(sorted, events)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	return sorted, nil
+}
+
+// GetRecord returns a copy of the stored record, like GetHistory - EndCall
+// mutates the store's own *CallRecord in place, so handing out the live
+// pointer would race a concurrent json.Marshal of the same struct.
+func (s *MemoryHistoryStore) GetRecord(ctx context.Context, sessionID string) (*CallRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, exists := s.records[sessionID]
+	if !exists {
+		return nil, ErrCallRecordNotFound
+	}
+	cp := *record
+	return &cp, nil
+}
+
+// PostgresHistoryStore persists events and call records via pgx. Schema
+// (created out of band by migrations):
+//
+//	CREATE TABLE call_events (
+//	    id BIGSERIAL PRIMARY KEY,
+//	    session_id TEXT NOT NULL,
+//	    type TEXT NOT NULL,
+//	    initiator TEXT NOT NULL,
+//	    reason TEXT,
+//	    timestamp TIMESTAMPTZ NOT NULL
+//	);
+//	CREATE TABLE call_records (
+//	    session_id TEXT PRIMARY KEY,
+//	    caller TEXT NOT NULL,
+//	    target TEXT NOT NULL,
+//	    type TEXT NOT NULL,
+//	    started_at TIMESTAMPTZ NOT NULL,
+//	    ended_at TIMESTAMPTZ,
+//	    duration_seconds DOUBLE PRECISION,
+//	    end_reason TEXT
+//	);
+type PostgresHistoryStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresHistoryStore(ctx context.Context, dsn string) (*PostgresHistoryStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	return &PostgresHistoryStore{pool: pool}, nil
+}
+
+func (s *PostgresHistoryStore) RecordEvent(ctx context.Context, event *CallEvent) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO call_events (session_id, type, initiator, reason, timestamp) VALUES ($1, $2, $3, $4, $5)`,
+		event.SessionID, event.Type, event.Initiator, event.Reason, event.Timestamp)
+	return err
+}
+
+func (s *PostgresHistoryStore) StartCall(ctx context.Context, record *CallRecord) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO call_records (session_id, caller, target, type, started_at) VALUES ($1, $2, $3, $4, $5)`,
+		record.SessionID, record.Caller, record.Target, record.Type, record.StartedAt)
+	return err
+}
+
+func (s *PostgresHistoryStore) EndCall(ctx context.Context, sessionID string, endedAt time.Time, reason string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE call_records
+		 SET ended_at = $2, duration_seconds = EXTRACT(EPOCH FROM ($2 - started_at)), end_reason = $3
+		 WHERE session_id = $1`,
+		sessionID, endedAt, reason)
+	return err
+}
+
+func (s *PostgresHistoryStore) GetHistory(ctx context.Context, username, peer string, limit int, before time.Time) ([]*CallRecord, error) {
+	query := `SELECT session_id, caller, target, type, started_at, ended_at, duration_seconds, end_reason
+	          FROM call_records WHERE (caller = $1 OR target = $1)`
+	args := []interface{}{username}
+
+	if peer != "" {
+		query += fmt.Sprintf(" AND (caller = $%d OR target = $%d)", len(args)+1, len(args)+1)
+		args = append(args, peer)
+	}
+	if !before.IsZero() {
+		query += fmt.Sprintf(" AND started_at < $%d", len(args)+1)
+		args = append(args, before)
+	}
+	query += fmt.Sprintf(" ORDER BY started_at DESC LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*CallRecord
+	for rows.Next() {
+		var record CallRecord
+		var duration *float64
+		if err := rows.Scan(&record.SessionID, &record.Caller, &record.Target, &record.Type,
+			&record.StartedAt, &record.EndedAt, &duration, &record.EndReason); err != nil {
+			return nil, err
+		}
+		if duration != nil {
+			record.Duration = *duration
+		}
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+func (s *PostgresHistoryStore) GetEvents(ctx context.Context, sessionID string) ([]*CallEvent, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT session_id, type, initiator, reason, timestamp FROM call_events WHERE session_id = $1 ORDER BY timestamp ASC`,
+		sessionID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*CallEvent
+	for rows.Next() {
+		var event CallEvent
+		if err := rows.Scan(&event.SessionID, &event.Type, &event.Initiator, &event.Reason, &event.Timestamp); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (s *PostgresHistoryStore) GetRecord(ctx context.Context, sessionID string) (*CallRecord, error) {
+	var record CallRecord
+	var duration *float64
+	err := s.pool.QueryRow(ctx,
+		`SELECT session_id, caller, target, type, started_at, ended_at, duration_seconds, end_reason
+		 FROM call_records WHERE session_id = $1`,
+		sessionID,
+	).Scan(&record.SessionID, &record.Caller, &record.Target, &record.Type,
+		&record.StartedAt, &record.EndedAt, &duration, &record.EndReason)
+	if err == pgx.ErrNoRows {
+		return nil, ErrCallRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if duration != nil {
+		record.Duration = *duration
+	}
+	return &record, nil
+}
+
+// recordCallEvent stores an event in historyStore and logs on failure
+// rather than surfacing it to the caller - history is best-effort and
+// should never block a signaling handler.
+func recordCallEvent(ctx context.Context, sessionID, eventType, initiator, reason string) {
+	err := historyStore.RecordEvent(ctx, &CallEvent{
+		SessionID: sessionID,
+		Type:      eventType,
+		Initiator: initiator,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to record call event", "session_id", sessionID, "error", err)
+	}
+}
+
+// GET /api/history?limit=&before=&peer=
+func handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+	ctx := r.Context()
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		if parsed, err := time.Parse(time.RFC3339, v); err == nil {
+			before = parsed
+		}
+	}
+
+	peer := r.URL.Query().Get("peer")
+
+	records, err := historyStore.GetHistory(ctx, username, peer, limit, before)
+	if err != nil {
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// GET /api/history/{sessionId}
+func handleGetHistoryEvents(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+	ctx := r.Context()
+
+	record, err := historyStore.GetRecord(ctx, sessionID)
+	if err == ErrCallRecordNotFound {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return
+	}
+	if record.Caller != username && record.Target != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	events, err := historyStore.GetEvents(ctx, sessionID)
+	if err != nil {
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// Route handler for /api/history and /api/history/{sessionId}
+func handleHistoryRoutes(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path == "/api/history" {
+		authMiddleware(handleGetHistory)(w, r)
+		return
+	}
+
+	sessionID := path[len("/api/history/"):]
+	if sessionID == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handleGetHistoryEvents(w, r, sessionID)
+	})(w, r)
+}
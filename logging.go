@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// logger emits structured JSON logs so they can be parsed by a log
+// aggregator; replaces the original log.Printf calls throughout the
+// handlers.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+type contextKey string
+
+const requestIDKey contextKey = "requestId"
+
+// withRequestID assigns each incoming request an ID (reusing an inbound
+// X-Request-ID if the caller/proxy already set one) and stores it on the
+// request context so every log line for that request can be correlated.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		w.Header().Set("X-Request-ID", requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loggerFromContext returns a logger annotated with the request ID carried
+// on ctx, if any.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok {
+		return logger.With("request_id", requestID)
+	}
+	return logger
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
@@ -16,19 +18,13 @@ import (
 
 // Configuration
 const (
+	// SecretKey is the fallback HS256 signing secret used when AUTH_SECRET
+	// is not set. Set AUTH_SECRET in any real deployment.
 	SecretKey = "123"
 	Port      = ":8000"
 )
 
 // Models
-type LoginRequest struct {
-	Username string `json:"username"`
-}
-
-type LoginResponse struct {
-	Token string `json:"token"`
-}
-
 type SessionCreateRequest struct {
 	TargetUsername string `json:"targetUsername"`
 	Type           string `json:"type"` // "video" or "audio"
@@ -45,52 +41,29 @@ type Session struct {
 
 type WebSocketMessage struct {
 	Event string                 `json:"event"`
+	From  string                 `json:"from,omitempty"`
+	To    string                 `json:"to,omitempty"`
 	Data  map[string]interface{} `json:"data"`
 }
 
-type Claims struct {
-	Username string `json:"sub"`
-	jwt.RegisteredClaims
-}
-
-// In-memory storage
+// Local connection state. Session data itself lives in sessionStore, which
+// may be backed by memory, Redis, or Postgres depending on config - see
+// store.go.
 var (
-	sessions      = make(map[string]*Session)        // sessionId -> Session
-	userSessions  = make(map[string]string)          // username -> sessionId
-	wsConnections = make(map[string]*websocket.Conn) // username -> WebSocket
-	mu            sync.RWMutex                       // Mutex for thread safety
+	wsConnections = make(map[string]*Client) // username -> Client
+	mu            sync.RWMutex               // Mutex for thread safety
 	upgrader      = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
-)
 
-// JWT Functions
-func createToken(username string) (string, error) {
-	claims := Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(60 * time.Minute)),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(SecretKey))
-}
-
-func verifyToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	})
-
-	if err != nil {
-		return "", err
-	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.Username, nil
-	}
-
-	return "", fmt.Errorf("invalid token")
-}
+	sessionStore SessionStore
+	notifier     Notifier
+	turnConfig   TURNConfig
+	userStore    UserStore
+	authConfig   AuthConfig
+	historyStore HistoryStore
+	wsConfig     WSConfig
+)
 
 // Middleware to verify JWT
 func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -102,7 +75,7 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		tokenString := authHeader[7:] // Remove "Bearer "
-		username, err := verifyToken(tokenString)
+		username, err := verifyToken(r.Context(), tokenString)
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
@@ -116,36 +89,6 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 // REST API Handlers
 
-// POST /api/auth/login
-func handleLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	if req.Username == "" {
-		http.Error(w, "Username required", http.StatusBadRequest)
-		return
-	}
-
-	token, err := createToken(req.Username)
-	if err != nil {
-		http.Error(w, "Failed to create token", http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("User logged in: %s", req.Username)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{Token: token})
-}
-
 // POST /api/session - Create new session
 func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -161,23 +104,23 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	ctx := r.Context()
 
-	// Check if user already has active session
-	if _, exists := userSessions[username]; exists {
-		http.Error(w, "Already in active session", http.StatusBadRequest)
+	if req.TargetUsername == username {
+		http.Error(w, "Cannot call yourself", http.StatusBadRequest)
 		return
 	}
 
-	// Check if target is busy
-	if _, exists := userSessions[req.TargetUsername]; exists {
-		http.Error(w, "Target user is busy", http.StatusConflict)
+	// Fast-path rejection before paying for a CreateSession round-trip.
+	// This is just an optimization - it races a concurrent caller and can
+	// go either way, so it must not be relied on for correctness. The
+	// actual busy-check is the atomic SetUserSessionIfAbsent below.
+	if _, err := sessionStore.GetUserSession(ctx, username); err == nil {
+		http.Error(w, "Already in active session", http.StatusBadRequest)
 		return
 	}
-
-	if req.TargetUsername == username {
-		http.Error(w, "Cannot call yourself", http.StatusBadRequest)
+	if _, err := sessionStore.GetUserSession(ctx, req.TargetUsername); err == nil {
+		http.Error(w, "Target user is busy", http.StatusConflict)
 		return
 	}
 
@@ -191,22 +134,57 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: time.Now(),
 	}
 
-	sessions[session.SessionID] = session
-	userSessions[username] = session.SessionID
-	userSessions[req.TargetUsername] = session.SessionID
+	if err := sessionStore.CreateSession(ctx, session); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
 
-	log.Printf("Session created: %s -> %s", username, req.TargetUsername)
+	// SetUserSessionIfAbsent is the real busy-check: it atomically claims
+	// the username -> session pointer only if none exists yet, so two
+	// concurrent calls targeting the same user can't both succeed. Any
+	// failure past this point rolls back what was already claimed so we
+	// never return 200 with a session nobody can look up.
+	callerFree, err := sessionStore.SetUserSessionIfAbsent(ctx, username, session.SessionID)
+	if err != nil {
+		sessionStore.DeleteSession(ctx, session.SessionID)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	if !callerFree {
+		sessionStore.DeleteSession(ctx, session.SessionID)
+		http.Error(w, "Already in active session", http.StatusBadRequest)
+		return
+	}
 
-	// Notify target via WebSocket
-	if conn, ok := wsConnections[req.TargetUsername]; ok {
-		go func() {
-			conn.WriteJSON(map[string]interface{}{
-				"event": "session_updated",
-				"data":  session,
-			})
-		}()
+	targetFree, err := sessionStore.SetUserSessionIfAbsent(ctx, req.TargetUsername, session.SessionID)
+	if err != nil {
+		sessionStore.DeleteUserSession(ctx, username)
+		sessionStore.DeleteSession(ctx, session.SessionID)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	if !targetFree {
+		sessionStore.DeleteUserSession(ctx, username)
+		sessionStore.DeleteSession(ctx, session.SessionID)
+		http.Error(w, "Target user is busy", http.StatusConflict)
+		return
 	}
 
+	sessionsByStatus.WithLabelValues("pending").Inc()
+
+	historyStore.StartCall(ctx, &CallRecord{
+		SessionID: session.SessionID,
+		Caller:    session.Caller,
+		Target:    session.Target,
+		Type:      session.Type,
+		StartedAt: session.CreatedAt,
+	})
+	recordCallEvent(ctx, session.SessionID, "pending", username, "")
+
+	loggerFromContext(ctx).Info("session created", "caller", username, "target", req.TargetUsername)
+
+	notifier.Notify(ctx, req.TargetUsername, "session_updated", session)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(session)
 }
@@ -219,18 +197,16 @@ func handleGetSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	username := r.Header.Get("X-Username")
+	ctx := r.Context()
 
-	mu.RLock()
-	defer mu.RUnlock()
-
-	sessionID, exists := userSessions[username]
-	if !exists {
+	sessionID, err := sessionStore.GetUserSession(ctx, username)
+	if err != nil {
 		http.Error(w, "No active session", http.StatusNotFound)
 		return
 	}
 
-	session, exists := sessions[sessionID]
-	if !exists {
+	session, err := sessionStore.GetSession(ctx, sessionID)
+	if err != nil {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
@@ -247,18 +223,16 @@ func handleAcceptSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	username := r.Header.Get("X-Username")
+	ctx := r.Context()
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	sessionID, exists := userSessions[username]
-	if !exists {
+	sessionID, err := sessionStore.GetUserSession(ctx, username)
+	if err != nil {
 		http.Error(w, "No pending session", http.StatusNotFound)
 		return
 	}
 
-	session, exists := sessions[sessionID]
-	if !exists {
+	session, err := sessionStore.GetSession(ctx, sessionID)
+	if err != nil {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
@@ -273,30 +247,30 @@ func handleAcceptSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update status
+	// Update status atomically: UpdateSessionIfStatus only applies if the
+	// session is still "pending" in the store, so a concurrent accept,
+	// decline, or cancel can't both proceed and double-count metrics.
 	session.Status = "active"
+	ok, err := sessionStore.UpdateSessionIfStatus(ctx, session, "pending")
+	if err != nil {
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Session not pending", http.StatusBadRequest)
+		return
+	}
 
-	log.Printf("Session accepted: %s", sessionID)
+	handshakeLatencySeconds.Observe(time.Since(session.CreatedAt).Seconds())
+	sessionsByStatus.WithLabelValues("pending").Dec()
+	sessionsByStatus.WithLabelValues("active").Inc()
+	recordCallEvent(ctx, sessionID, "active", username, "")
 
-	// Notify caller
-	if conn, ok := wsConnections[session.Caller]; ok {
-		go func() {
-			conn.WriteJSON(map[string]interface{}{
-				"event": "session_updated",
-				"data":  session,
-			})
-		}()
-	}
+	loggerFromContext(ctx).Info("session accepted", "session_id", sessionID)
 
-	// Also notify target (acceptor)
-	if conn, ok := wsConnections[session.Target]; ok {
-		go func() {
-			conn.WriteJSON(map[string]interface{}{
-				"event": "session_updated",
-				"data":  session,
-			})
-		}()
-	}
+	// Notify caller and target (acceptor)
+	notifier.Notify(ctx, session.Caller, "session_updated", session)
+	notifier.Notify(ctx, session.Target, "session_updated", session)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(session)
@@ -310,18 +284,16 @@ func handleDeclineSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	username := r.Header.Get("X-Username")
+	ctx := r.Context()
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	sessionID, exists := userSessions[username]
-	if !exists {
+	sessionID, err := sessionStore.GetUserSession(ctx, username)
+	if err != nil {
 		http.Error(w, "No pending session", http.StatusNotFound)
 		return
 	}
 
-	session, exists := sessions[sessionID]
-	if !exists {
+	session, err := sessionStore.GetSession(ctx, sessionID)
+	if err != nil {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
@@ -331,25 +303,36 @@ func handleDeclineSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Atomically move the session out of its observed status so a
+	// concurrent decline/cancel/disconnect on the same session can't also
+	// proceed and double-fire history/metrics/notifications.
+	previousStatus := session.Status
 	session.Status = "declined"
+	ok, err := sessionStore.UpdateSessionIfStatus(ctx, session, previousStatus)
+	if err != nil {
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Session already ended", http.StatusConflict)
+		return
+	}
+
+	sessionsByStatus.WithLabelValues(previousStatus).Dec()
+	recordSessionEnded(session, "declined")
+	recordCallEvent(ctx, sessionID, "declined", username, "")
+	historyStore.EndCall(ctx, sessionID, time.Now(), "declined")
 
-	log.Printf("Session declined: %s", sessionID)
+	loggerFromContext(ctx).Info("session declined", "session_id", sessionID)
 
 	// Notify caller
 	caller := session.Caller
-	if conn, ok := wsConnections[caller]; ok {
-		go func() {
-			conn.WriteJSON(map[string]interface{}{
-				"event": "session_updated",
-				"data":  session,
-			})
-		}()
-	}
+	notifier.Notify(ctx, caller, "session_updated", session)
 
 	// Cleanup
-	delete(userSessions, username)
-	delete(userSessions, caller)
-	delete(sessions, sessionID)
+	sessionStore.DeleteUserSession(ctx, username)
+	sessionStore.DeleteUserSession(ctx, caller)
+	sessionStore.DeleteSession(ctx, sessionID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(session)
@@ -363,25 +346,41 @@ func handleCancelSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	username := r.Header.Get("X-Username")
+	ctx := r.Context()
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	sessionID, exists := userSessions[username]
-	if !exists {
+	sessionID, err := sessionStore.GetUserSession(ctx, username)
+	if err != nil {
 		http.Error(w, "No active session", http.StatusNotFound)
 		return
 	}
 
-	session, exists := sessions[sessionID]
-	if !exists {
+	session, err := sessionStore.GetSession(ctx, sessionID)
+	if err != nil {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
 
+	// Atomically move the session out of its observed status so a
+	// concurrent decline/cancel/disconnect on the same session can't also
+	// proceed and double-fire history/metrics/notifications.
+	previousStatus := session.Status
 	session.Status = "cancelled"
+	ok, err := sessionStore.UpdateSessionIfStatus(ctx, session, previousStatus)
+	if err != nil {
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Session already ended", http.StatusConflict)
+		return
+	}
 
-	log.Printf("Session cancelled: %s", sessionID)
+	sessionsByStatus.WithLabelValues(previousStatus).Dec()
+	recordSessionEnded(session, "cancelled")
+	recordCallEvent(ctx, sessionID, "cancelled", username, "")
+	historyStore.EndCall(ctx, sessionID, time.Now(), "cancelled")
+
+	loggerFromContext(ctx).Info("session cancelled", "session_id", sessionID)
 
 	// Notify other party
 	otherUser := session.Target
@@ -389,19 +388,12 @@ func handleCancelSession(w http.ResponseWriter, r *http.Request) {
 		otherUser = session.Caller
 	}
 
-	if conn, ok := wsConnections[otherUser]; ok {
-		go func() {
-			conn.WriteJSON(map[string]interface{}{
-				"event": "session_updated",
-				"data":  session,
-			})
-		}()
-	}
+	notifier.Notify(ctx, otherUser, "session_updated", session)
 
 	// Cleanup
-	delete(userSessions, username)
-	delete(userSessions, otherUser)
-	delete(sessions, sessionID)
+	sessionStore.DeleteUserSession(ctx, username)
+	sessionStore.DeleteUserSession(ctx, otherUser)
+	sessionStore.DeleteSession(ctx, sessionID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(session)
@@ -409,6 +401,8 @@ func handleCancelSession(w http.ResponseWriter, r *http.Request) {
 
 // WebSocket Handler
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	// Get token from query
 	token := r.URL.Query().Get("token")
 	if token == "" {
@@ -416,7 +410,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username, err := verifyToken(token)
+	username, err := verifyToken(ctx, token)
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
@@ -425,38 +419,52 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Upgrade to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logger.Error("websocket upgrade error", "error", err)
 		return
 	}
-	defer conn.Close()
+
+	// Client owns conn from here on: writePump is the only goroutine that
+	// writes to it, and it closes conn once it drains.
+	client := NewClient(username, conn, wsConfig)
+	go client.writePump()
+	client.initReadDeadline()
 
 	// Store connection
 	mu.Lock()
-	wsConnections[username] = conn
+	wsConnections[username] = client
 	mu.Unlock()
+	activeWebSocketConnections.Inc()
+	wsWG.Add(1)
+	defer wsWG.Done()
 
-	log.Printf("WebSocket connected: %s", username)
+	loggerFromContext(ctx).Info("websocket connected", "username", username)
 
 	// Handle messages
 	for {
 		var msg WebSocketMessage
 		if err := conn.ReadJSON(&msg); err != nil {
-			log.Printf("WebSocket read error for %s: %v", username, err)
+			loggerFromContext(ctx).Info("websocket read error", "username", username, "error", err)
 			break
 		}
 
 		if msg.Event == "signal" {
-			// Forward signal to other party
-			mu.RLock()
-			sessionID, exists := userSessions[username]
-			if !exists {
-				mu.RUnlock()
+			roomsMu.RLock()
+			roomID, inRoom := userRoom[username]
+			roomsMu.RUnlock()
+
+			if inRoom {
+				forwardRoomSignal(ctx, roomID, username, msg)
 				continue
 			}
 
-			session, exists := sessions[sessionID]
-			if !exists || session.Status != "active" {
-				mu.RUnlock()
+			// Fall back to 1:1 session signaling
+			sessionID, err := sessionStore.GetUserSession(ctx, username)
+			if err != nil {
+				continue
+			}
+
+			session, err := sessionStore.GetSession(ctx, sessionID)
+			if err != nil || session.Status != "active" {
 				continue
 			}
 
@@ -465,54 +473,57 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				otherUser = session.Caller
 			}
 
-			otherConn, exists := wsConnections[otherUser]
-			mu.RUnlock()
-
-			if exists {
-				go func() {
-					otherConn.WriteJSON(map[string]interface{}{
-						"event": "signal",
-						"data":  msg.Data,
-					})
-				}()
-				log.Printf("Signal forwarded: %s -> %s", username, otherUser)
+			if err := notifier.Notify(ctx, otherUser, "signal", msg.Data); err == nil {
+				signalMessagesForwardedTotal.Inc()
+				loggerFromContext(ctx).Info("signal forwarded", "from", username, "to", otherUser)
 			}
 		}
 	}
 
+	client.Close(websocket.CloseNormalClosure, "")
+
 	// Cleanup on disconnect
 	mu.Lock()
 	delete(wsConnections, username)
-
-	// Cancel session if exists
-	if sessionID, exists := userSessions[username]; exists {
-		if session, exists := sessions[sessionID]; exists {
+	mu.Unlock()
+	activeWebSocketConnections.Dec()
+
+	// Cancel session if exists. UpdateSessionIfStatus only applies if the
+	// session is still in the status we just observed, so this can't race
+	// a concurrent decline/cancel/accept on the same session into
+	// double-firing history, metrics, or notifications.
+	if sessionID, err := sessionStore.GetUserSession(ctx, username); err == nil {
+		if session, err := sessionStore.GetSession(ctx, sessionID); err == nil {
+			previousStatus := session.Status
 			session.Status = "disconnected"
-
-			otherUser := session.Target
-			if session.Target == username {
-				otherUser = session.Caller
-			}
-
-			// Notify other user
-			if otherConn, ok := wsConnections[otherUser]; ok {
-				go func() {
-					otherConn.WriteJSON(map[string]interface{}{
-						"event": "session_updated",
-						"data":  session,
-					})
-				}()
+			ok, err := sessionStore.UpdateSessionIfStatus(ctx, session, previousStatus)
+			if err != nil {
+				loggerFromContext(ctx).Error("failed to update session on disconnect", "session_id", sessionID, "error", err)
+			} else if ok {
+				sessionsByStatus.WithLabelValues(previousStatus).Dec()
+				recordSessionEnded(session, "disconnected")
+				recordCallEvent(ctx, sessionID, "disconnected", username, "")
+				historyStore.EndCall(ctx, sessionID, time.Now(), "disconnected")
+
+				otherUser := session.Target
+				if session.Target == username {
+					otherUser = session.Caller
+				}
+
+				notifier.Notify(ctx, otherUser, "session_updated", session)
+
+				// Cleanup
+				sessionStore.DeleteUserSession(ctx, username)
+				sessionStore.DeleteUserSession(ctx, otherUser)
+				sessionStore.DeleteSession(ctx, sessionID)
 			}
-
-			// Cleanup
-			delete(userSessions, username)
-			delete(userSessions, otherUser)
-			delete(sessions, sessionID)
 		}
 	}
-	mu.Unlock()
 
-	log.Printf("WebSocket disconnected: %s", username)
+	// Leave room if member of one
+	leaveRoomOnDisconnect(ctx, username)
+
+	loggerFromContext(ctx).Info("websocket disconnected", "username", username)
 }
 
 // Root handler
@@ -521,9 +532,11 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		"name":    "WebRTC Signaling Server (Go)",
 		"version": "1.0.0",
 		"endpoints": map[string]string{
-			"auth":      "/api/auth/login",
-			"session":   "/api/session",
-			"websocket": "/ws?token=YOUR_JWT_TOKEN",
+			"auth":            "/api/auth/login",
+			"session":         "/api/session",
+			"room":            "/api/room",
+			"turnCredentials": "/api/turn-credentials",
+			"websocket":       "/ws?token=YOUR_JWT_TOKEN",
 		},
 	}
 
@@ -556,16 +569,86 @@ func handleSessionRoutes(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// initStore selects sessionStore and notifier based on STORE_BACKEND
+// ("memory" (default), "redis", or "postgres") and STORE_DSN. Redis is used
+// for both the store and the signal fan-out notifier; Postgres sessions
+// still fan out locally only, matching a single-instance deployment.
+func initStore(ctx context.Context) {
+	backend := StoreBackend(os.Getenv("STORE_BACKEND"))
+	dsn := os.Getenv("STORE_DSN")
+
+	store, err := NewSessionStore(ctx, backend, dsn)
+	if err != nil {
+		log.Fatalf("Failed to initialize session store: %v", err)
+	}
+	sessionStore = store
+
+	if backend == StoreBackendRedis {
+		redisStore := store.(*RedisSessionStore)
+		redisNotifier := NewRedisNotifier(redisStore.client)
+		redisNotifier.StartSubscriber(ctx)
+		notifier = redisNotifier
+	} else {
+		if backend == StoreBackendPostgres {
+			log.Printf("WARNING: STORE_BACKEND=postgres persists sessions across instances but signaling still fans out locally only; peers connected to other instances will not receive notifications. Use STORE_BACKEND=redis for multi-instance signaling.")
+		}
+		notifier = LocalNotifier{}
+	}
+}
+
+// initAuth selects userStore based on USER_STORE_BACKEND ("memory"
+// (default) or "postgres") and USER_STORE_DSN, and loads signing config.
+func initAuth(ctx context.Context) {
+	backend := StoreBackend(os.Getenv("USER_STORE_BACKEND"))
+	dsn := os.Getenv("USER_STORE_DSN")
+
+	store, err := NewUserStore(ctx, backend, dsn)
+	if err != nil {
+		log.Fatalf("Failed to initialize user store: %v", err)
+	}
+	userStore = store
+	authConfig = loadAuthConfig()
+}
+
+// initHistory selects historyStore based on HISTORY_STORE_BACKEND ("memory"
+// (default) or "postgres") and HISTORY_STORE_DSN.
+func initHistory(ctx context.Context) {
+	backend := StoreBackend(os.Getenv("HISTORY_STORE_BACKEND"))
+	dsn := os.Getenv("HISTORY_STORE_DSN")
+
+	store, err := NewHistoryStore(ctx, backend, dsn)
+	if err != nil {
+		log.Fatalf("Failed to initialize history store: %v", err)
+	}
+	historyStore = store
+}
+
 func main() {
+	ctx := context.Background()
+	initStore(ctx)
+	initAuth(ctx)
+	initHistory(ctx)
+	turnConfig = loadTURNConfig()
+	wsConfig = loadWSConfig()
+
 	mux := http.NewServeMux()
 
 	// Routes
 	mux.HandleFunc("/", handleRoot)
+	mux.HandleFunc("/api/auth/register", handleRegister)
 	mux.HandleFunc("/api/auth/login", handleLogin)
+	mux.HandleFunc("/api/auth/refresh", handleRefresh)
+	mux.HandleFunc("/api/auth/logout", handleLogout)
 	mux.HandleFunc("/api/session", handleSessionRoutes)
 	mux.HandleFunc("/api/session/accept", handleSessionRoutes)
 	mux.HandleFunc("/api/session/decline", handleSessionRoutes)
+	mux.HandleFunc("/api/room", handleRoomRoutes)
+	mux.HandleFunc("/api/room/", handleRoomRoutes)
+	mux.HandleFunc("/api/history", handleHistoryRoutes)
+	mux.HandleFunc("/api/history/", handleHistoryRoutes)
+	mux.HandleFunc("/api/turn-credentials", authMiddleware(handleTURNCredentials))
 	mux.HandleFunc("/ws", handleWebSocket)
+	registerMetricsRoutes(mux)
 
 	// CORS middleware
 	handler := cors.New(cors.Options{
@@ -573,9 +656,41 @@ func main() {
 		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Authorization", "Content-Type"},
 		AllowCredentials: true,
-	}).Handler(mux)
+	}).Handler(withRequestID(mux))
+
+	server := &http.Server{Addr: Port, Handler: handler}
+
+	logger.Info("WebRTC signaling server starting", "port", Port)
+	logger.Info("websocket endpoint ready", "path", "/ws")
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("listen: %v", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	logger.Info("shutdown signal received, draining websocket connections")
+
+	// server.Shutdown closes the listener before it does anything else, so
+	// starting it first stops new /ws upgrades from sneaking in during the
+	// drain below - a client that connected after closeAllClients had
+	// already enumerated wsConnections would otherwise get no going_away
+	// frame and wouldn't be covered by waitForDrain's wsWG.Wait.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- server.Shutdown(shutdownCtx) }()
+
+	closeAllClients()
+	waitForDrain(10 * time.Second)
+
+	if err := <-shutdownDone; err != nil {
+		logger.Error("server shutdown error", "error", err)
+	}
 
-	log.Printf("ðŸš€ WebRTC Signaling Server starting on %s", Port)
-	log.Printf("ðŸ“¡ WebSocket endpoint: ws://localhost%s/ws", Port)
-	log.Fatal(http.ListenAndServe(Port, handler))
+	logger.Info("server stopped")
 }
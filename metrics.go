@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Prometheus metrics. Handlers that touch session/signal state update these
+// directly rather than going through a generic middleware, since the
+// interesting counters (declines vs cancels, handshake latency) depend on
+// which branch a handler takes, not just that it was called.
+var (
+	activeWebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webrtc_active_websocket_connections",
+		Help: "Number of currently connected WebSocket clients.",
+	})
+
+	sessionsByStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "webrtc_sessions_by_status",
+		Help: "Number of sessions currently in each status.",
+	}, []string{"status"})
+
+	signalMessagesForwardedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webrtc_signal_messages_forwarded_total",
+		Help: "Total number of signal messages forwarded between peers.",
+	})
+
+	sessionDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webrtc_session_duration_seconds",
+		Help:    "Duration of completed sessions from creation to teardown.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	handshakeLatencySeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webrtc_handshake_latency_seconds",
+		Help:    "Time from a session entering pending to becoming active.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	sessionsDeclinedTotal  = promauto.NewCounter(prometheus.CounterOpts{Name: "webrtc_sessions_declined_total", Help: "Total number of sessions declined by the target."})
+	sessionsCancelledTotal = promauto.NewCounter(prometheus.CounterOpts{Name: "webrtc_sessions_cancelled_total", Help: "Total number of sessions cancelled by a participant."})
+
+	websocketSlowConsumerDisconnectsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webrtc_websocket_slow_consumer_disconnects_total",
+		Help: "Total number of WebSocket clients dropped for exceeding their outbound event queue.",
+	})
+)
+
+// recordSessionEnded observes the duration of a completed session and bumps
+// the matching end-reason counter.
+func recordSessionEnded(session *Session, reason string) {
+	sessionDurationSeconds.Observe(time.Since(session.CreatedAt).Seconds())
+	switch reason {
+	case "declined":
+		sessionsDeclinedTotal.Inc()
+	case "cancelled":
+		sessionsCancelledTotal.Inc()
+	}
+}
+
+// DebugStatus is the payload served by the admin-gated /debug/status
+// endpoint, mirroring the shape of the Prometheus gauges so either surface
+// can be used to eyeball server health.
+type DebugStatus struct {
+	ActiveWebSocketConnections int            `json:"activeWebSocketConnections"`
+	SessionsByStatus           map[string]int `json:"sessionsByStatus"`
+	Timestamp                  time.Time      `json:"timestamp"`
+}
+
+// adminMiddleware gates a handler behind a static bearer token configured
+// via ADMIN_TOKEN, the same shape authMiddleware uses for user tokens but
+// without JWT parsing - this is an operator credential, not a user one.
+func adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		if adminToken == "" {
+			http.Error(w, "Admin endpoint not configured", http.StatusServiceUnavailable)
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(authHeader), []byte("Bearer "+adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// sessionsByStatusSnapshot reads the current value of every status label on
+// the sessionsByStatus gauge vec. It's the Prometheus handlers already
+// maintain incrementally via WithLabelValues(...).Inc()/Dec(), so this is
+// the one place that needs to know the full set of statuses in play.
+func sessionsByStatusSnapshot() map[string]int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		sessionsByStatus.Collect(ch)
+		close(ch)
+	}()
+
+	snapshot := make(map[string]int)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			continue
+		}
+		for _, label := range pb.GetLabel() {
+			if label.GetName() == "status" {
+				snapshot[label.GetValue()] = int(pb.GetGauge().GetValue())
+			}
+		}
+	}
+	return snapshot
+}
+
+// GET /debug/status
+func handleDebugStatus(w http.ResponseWriter, r *http.Request) {
+	mu.RLock()
+	connCount := len(wsConnections)
+	mu.RUnlock()
+
+	status := DebugStatus{
+		ActiveWebSocketConnections: connCount,
+		SessionsByStatus:           sessionsByStatusSnapshot(),
+		Timestamp:                  time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func registerMetricsRoutes(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/debug/status", adminMiddleware(handleDebugStatus))
+}
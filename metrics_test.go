@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func withAdminToken(t *testing.T, token string) {
+	t.Helper()
+	prev, had := os.LookupEnv("ADMIN_TOKEN")
+	if token == "" {
+		os.Unsetenv("ADMIN_TOKEN")
+	} else {
+		os.Setenv("ADMIN_TOKEN", token)
+	}
+	t.Cleanup(func() {
+		if had {
+			os.Setenv("ADMIN_TOKEN", prev)
+		} else {
+			os.Unsetenv("ADMIN_TOKEN")
+		}
+	})
+}
+
+func TestAdminMiddlewareRejectsWhenUnconfigured(t *testing.T) {
+	withAdminToken(t, "")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/status", nil)
+	adminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next should not be called when ADMIN_TOKEN is unset")
+	})(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAdminMiddlewareRejectsWrongToken(t *testing.T) {
+	withAdminToken(t, "s3cr3t")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/status", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	adminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next should not be called with a wrong token")
+	})(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminMiddlewareAllowsCorrectToken(t *testing.T) {
+	withAdminToken(t, "s3cr3t")
+
+	called := false
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/status", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	adminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})(rec, req)
+
+	if !called {
+		t.Error("next was not called with the correct token")
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestSessionsByStatusSnapshotReflectsGauge(t *testing.T) {
+	sessionsByStatus.WithLabelValues("pending").Inc()
+	sessionsByStatus.WithLabelValues("active").Inc()
+	sessionsByStatus.WithLabelValues("active").Inc()
+	t.Cleanup(func() {
+		sessionsByStatus.WithLabelValues("pending").Dec()
+		sessionsByStatus.WithLabelValues("active").Dec()
+		sessionsByStatus.WithLabelValues("active").Dec()
+	})
+
+	snapshot := sessionsByStatusSnapshot()
+	if snapshot["pending"] != 1 {
+		t.Errorf("pending = %d, want 1", snapshot["pending"])
+	}
+	if snapshot["active"] != 2 {
+		t.Errorf("active = %d, want 2", snapshot["active"])
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Room models a multi-party call. Unlike a Session (strictly 1:1), a Room
+// holds an arbitrary number of participants and lets the server route
+// signaling either point-to-point (mesh) or as a broadcast to everyone
+// else in the room.
+type Room struct {
+	ID           string                  `json:"id"`
+	Participants map[string]*Participant `json:"participants"`
+	CreatedAt    time.Time               `json:"createdAt"`
+	mu           sync.RWMutex            // guards Participants for this room only
+}
+
+type Participant struct {
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+type RoomCreateRequest struct {
+	RoomID string `json:"roomId"` // optional, generated when empty
+}
+
+// Room-scoped state. roomsMu guards the rooms/userRoom maps themselves
+// (creation, deletion, membership lookups); each Room has its own mutex
+// for participant mutation so a busy room doesn't stall lookups on others.
+var (
+	rooms    = make(map[string]*Room)  // roomId -> Room
+	userRoom = make(map[string]string) // username -> roomId
+	roomsMu  sync.RWMutex
+)
+
+func (rm *Room) snapshot() *Room {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	participants := make(map[string]*Participant, len(rm.Participants))
+	for k, v := range rm.Participants {
+		participants[k] = v
+	}
+	return &Room{ID: rm.ID, Participants: participants, CreatedAt: rm.CreatedAt}
+}
+
+// POST /api/room - create a new room
+func handleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+
+	var req RoomCreateRequest
+	_ = json.NewDecoder(r.Body).Decode(&req) // body is optional
+
+	roomID := req.RoomID
+	if roomID == "" {
+		roomID = uuid.New().String()
+	}
+
+	roomsMu.Lock()
+	if _, exists := rooms[roomID]; exists {
+		roomsMu.Unlock()
+		http.Error(w, "Room already exists", http.StatusConflict)
+		return
+	}
+
+	room := &Room{
+		ID:           roomID,
+		Participants: make(map[string]*Participant),
+		CreatedAt:    time.Now(),
+	}
+	room.Participants[username] = &Participant{Username: username, JoinedAt: time.Now()}
+	rooms[roomID] = room
+	userRoom[username] = roomID
+	roomsMu.Unlock()
+
+	loggerFromContext(r.Context()).Info("room created", "room_id", roomID, "username", username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room.snapshot())
+}
+
+// GET /api/room/{id}
+func handleGetRoom(w http.ResponseWriter, r *http.Request, roomID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomsMu.RLock()
+	room, exists := rooms[roomID]
+	roomsMu.RUnlock()
+	if !exists {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room.snapshot())
+}
+
+// POST /api/room/{id}/join
+func handleJoinRoom(w http.ResponseWriter, r *http.Request, roomID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+
+	roomsMu.Lock()
+	room, exists := rooms[roomID]
+	if !exists {
+		roomsMu.Unlock()
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if existingRoom, inRoom := userRoom[username]; inRoom && existingRoom != roomID {
+		roomsMu.Unlock()
+		http.Error(w, "Already in another room", http.StatusBadRequest)
+		return
+	}
+	userRoom[username] = roomID
+	roomsMu.Unlock()
+
+	room.mu.Lock()
+	room.Participants[username] = &Participant{Username: username, JoinedAt: time.Now()}
+	room.mu.Unlock()
+
+	loggerFromContext(r.Context()).Info("room joined", "username", username, "room_id", roomID)
+
+	notifyRoom(r.Context(), room, username, "participant_joined", map[string]interface{}{
+		"roomId":   roomID,
+		"username": username,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room.snapshot())
+}
+
+// POST /api/room/{id}/leave
+func handleLeaveRoom(w http.ResponseWriter, r *http.Request, roomID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+
+	roomsMu.Lock()
+	room, exists := rooms[roomID]
+	if !exists {
+		roomsMu.Unlock()
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	delete(userRoom, username)
+
+	room.mu.Lock()
+	delete(room.Participants, username)
+	empty := len(room.Participants) == 0
+	room.mu.Unlock()
+
+	if empty {
+		delete(rooms, roomID)
+	}
+	roomsMu.Unlock()
+
+	loggerFromContext(r.Context()).Info("room left", "room_id", roomID, "username", username)
+
+	if !empty {
+		notifyRoom(r.Context(), room, username, "participant_left", map[string]interface{}{
+			"roomId":   roomID,
+			"username": username,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyRoom broadcasts an event to every participant in the room except
+// sender, via notifier rather than wsConnections directly - so with
+// STORE_BACKEND=redis a participant connected to another instance behind
+// the load balancer still receives it.
+func notifyRoom(ctx context.Context, room *Room, sender string, event string, data map[string]interface{}) {
+	room.mu.RLock()
+	targets := make([]string, 0, len(room.Participants))
+	for username := range room.Participants {
+		if username != sender {
+			targets = append(targets, username)
+		}
+	}
+	room.mu.RUnlock()
+
+	for _, username := range targets {
+		if err := notifier.Notify(ctx, username, event, data); err != nil {
+			logger.Error("room notify failed", "username", username, "event", event, "error", err)
+		}
+	}
+}
+
+// forwardRoomSignal routes a "signal" event within a room. When msg.To is
+// set, it is delivered point-to-point (mesh negotiation between two
+// participants); otherwise it is broadcast to every other participant.
+// Delivery goes through notifier, like notifyRoom, for the same
+// multi-instance reason; "from" is folded into the data payload since
+// Notifier.Notify only has room for an event and a data blob.
+func forwardRoomSignal(ctx context.Context, roomID, from string, msg WebSocketMessage) {
+	roomsMu.RLock()
+	room, exists := rooms[roomID]
+	roomsMu.RUnlock()
+	if !exists {
+		return
+	}
+
+	data := make(map[string]interface{}, len(msg.Data)+1)
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	data["from"] = from
+
+	if msg.To != "" {
+		room.mu.RLock()
+		_, isMember := room.Participants[msg.To]
+		room.mu.RUnlock()
+		if !isMember {
+			return
+		}
+
+		if err := notifier.Notify(ctx, msg.To, "signal", data); err == nil {
+			logger.Info("room signal forwarded", "from", from, "to", msg.To, "room_id", roomID)
+		}
+		return
+	}
+
+	room.mu.RLock()
+	targets := make([]string, 0, len(room.Participants))
+	for participant := range room.Participants {
+		if participant != from {
+			targets = append(targets, participant)
+		}
+	}
+	room.mu.RUnlock()
+
+	for _, participant := range targets {
+		if err := notifier.Notify(ctx, participant, "signal", data); err != nil {
+			logger.Error("room signal delivery failed", "to", participant, "room_id", roomID, "error", err)
+		}
+	}
+	logger.Info("room signal broadcast", "from", from, "room_id", roomID)
+}
+
+// leaveRoomOnDisconnect removes a disconnected user from their room, if any,
+// mirroring the explicit leave endpoint.
+func leaveRoomOnDisconnect(ctx context.Context, username string) {
+	roomsMu.Lock()
+	roomID, inRoom := userRoom[username]
+	if !inRoom {
+		roomsMu.Unlock()
+		return
+	}
+	delete(userRoom, username)
+
+	room, exists := rooms[roomID]
+	if !exists {
+		roomsMu.Unlock()
+		return
+	}
+
+	room.mu.Lock()
+	delete(room.Participants, username)
+	empty := len(room.Participants) == 0
+	room.mu.Unlock()
+
+	if empty {
+		delete(rooms, roomID)
+	}
+	roomsMu.Unlock()
+
+	if !empty {
+		notifyRoom(ctx, room, username, "participant_left", map[string]interface{}{
+			"roomId":   roomID,
+			"username": username,
+		})
+	}
+}
+
+// Route handler to support REST-like routing for /api/room and /api/room/{id}[/join|/leave]
+func handleRoomRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/room")
+
+	if path == "" || path == "/" {
+		authMiddleware(handleCreateRoom)(w, r)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	roomID := segments[0]
+
+	switch {
+	case len(segments) == 1:
+		authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			handleGetRoom(w, r, roomID)
+		})(w, r)
+	case len(segments) == 2 && segments[1] == "join":
+		authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			handleJoinRoom(w, r, roomID)
+		})(w, r)
+	case len(segments) == 2 && segments[1] == "leave":
+		authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			handleLeaveRoom(w, r, roomID)
+		})(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
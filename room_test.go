@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// notifyRoom/forwardRoomSignal/leaveRoomOnDisconnect deliver via notifier
+// rather than wsConnections directly, so tests need one configured the same
+// way main() does for the default (non-Redis) backend.
+func init() {
+	notifier = LocalNotifier{}
+}
+
+// connectTestClient upgrades a connection to an httptest server into a
+// Client registered under username in wsConnections, mirroring what
+// handleWebSocket does at connect time, and returns the client-side
+// *websocket.Conn so the test can read whatever gets pushed to it.
+func connectTestClient(t *testing.T, username string) *websocket.Conn {
+	t.Helper()
+
+	registered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		client := NewClient(username, conn, WSConfig{PingTimeout: time.Minute, ClientEventQueue: 8})
+		go client.writePump()
+
+		mu.Lock()
+		wsConnections[username] = client
+		mu.Unlock()
+		close(registered)
+	}))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	<-registered
+
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(wsConnections, username)
+		mu.Unlock()
+	})
+
+	return conn
+}
+
+// expectNoMessage asserts conn has nothing to read within a short window.
+func expectNoMessage(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err == nil {
+		t.Errorf("expected no message, got %v", msg)
+	}
+	conn.SetReadDeadline(time.Time{})
+}
+
+func TestNotifyRoomBroadcastsToOthersOnly(t *testing.T) {
+	aliceConn := connectTestClient(t, "alice")
+	bobConn := connectTestClient(t, "bob")
+
+	room := &Room{
+		ID: "room1",
+		Participants: map[string]*Participant{
+			"alice": {Username: "alice"},
+			"bob":   {Username: "bob"},
+		},
+	}
+
+	notifyRoom(context.Background(), room, "alice", "participant_joined", map[string]interface{}{"username": "alice"})
+
+	var msg map[string]interface{}
+	if err := bobConn.ReadJSON(&msg); err != nil {
+		t.Fatalf("bob ReadJSON: %v", err)
+	}
+	if msg["event"] != "participant_joined" {
+		t.Errorf("event = %v, want participant_joined", msg["event"])
+	}
+
+	expectNoMessage(t, aliceConn)
+}
+
+func TestForwardRoomSignalPointToPoint(t *testing.T) {
+	aliceConn := connectTestClient(t, "alice")
+	bobConn := connectTestClient(t, "bob")
+	carolConn := connectTestClient(t, "carol")
+
+	roomsMu.Lock()
+	rooms["room1"] = &Room{
+		ID: "room1",
+		Participants: map[string]*Participant{
+			"alice": {Username: "alice"},
+			"bob":   {Username: "bob"},
+			"carol": {Username: "carol"},
+		},
+	}
+	roomsMu.Unlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, "room1")
+		roomsMu.Unlock()
+	})
+
+	forwardRoomSignal(context.Background(), "room1", "alice", WebSocketMessage{
+		Event: "signal",
+		To:    "bob",
+		Data:  map[string]interface{}{"sdp": "offer"},
+	})
+
+	var msg map[string]interface{}
+	if err := bobConn.ReadJSON(&msg); err != nil {
+		t.Fatalf("bob ReadJSON: %v", err)
+	}
+	data, _ := msg["data"].(map[string]interface{})
+	if data["from"] != "alice" {
+		t.Errorf("data.from = %v, want alice", data["from"])
+	}
+
+	expectNoMessage(t, aliceConn)
+	expectNoMessage(t, carolConn)
+}
+
+func TestForwardRoomSignalBroadcastsWhenNoTarget(t *testing.T) {
+	aliceConn := connectTestClient(t, "alice")
+	bobConn := connectTestClient(t, "bob")
+	carolConn := connectTestClient(t, "carol")
+
+	roomsMu.Lock()
+	rooms["room1"] = &Room{
+		ID: "room1",
+		Participants: map[string]*Participant{
+			"alice": {Username: "alice"},
+			"bob":   {Username: "bob"},
+			"carol": {Username: "carol"},
+		},
+	}
+	roomsMu.Unlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, "room1")
+		roomsMu.Unlock()
+	})
+
+	forwardRoomSignal(context.Background(), "room1", "alice", WebSocketMessage{
+		Event: "signal",
+		Data:  map[string]interface{}{"candidate": "c1"},
+	})
+
+	for _, conn := range []*websocket.Conn{bobConn, carolConn} {
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON: %v", err)
+		}
+		data, _ := msg["data"].(map[string]interface{})
+		if data["from"] != "alice" {
+			t.Errorf("data.from = %v, want alice", data["from"])
+		}
+	}
+
+	expectNoMessage(t, aliceConn)
+}
+
+func TestLeaveRoomOnDisconnectRemovesParticipantAndNotifies(t *testing.T) {
+	bobConn := connectTestClient(t, "bob")
+	_ = connectTestClient(t, "alice")
+
+	room := &Room{
+		ID: "room1",
+		Participants: map[string]*Participant{
+			"alice": {Username: "alice"},
+			"bob":   {Username: "bob"},
+		},
+	}
+
+	roomsMu.Lock()
+	rooms["room1"] = room
+	userRoom["alice"] = "room1"
+	roomsMu.Unlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, "room1")
+		delete(userRoom, "alice")
+		delete(userRoom, "bob")
+		roomsMu.Unlock()
+	})
+
+	leaveRoomOnDisconnect(context.Background(), "alice")
+
+	roomsMu.RLock()
+	_, stillInRoom := userRoom["alice"]
+	roomsMu.RUnlock()
+	if stillInRoom {
+		t.Error("alice should have been removed from userRoom")
+	}
+
+	room.mu.RLock()
+	_, stillParticipant := room.Participants["alice"]
+	room.mu.RUnlock()
+	if stillParticipant {
+		t.Error("alice should have been removed from room.Participants")
+	}
+
+	var msg map[string]interface{}
+	if err := bobConn.ReadJSON(&msg); err != nil {
+		t.Fatalf("bob ReadJSON: %v", err)
+	}
+	if msg["event"] != "participant_left" {
+		t.Errorf("event = %v, want participant_left", msg["event"])
+	}
+}
+
+func TestLeaveRoomOnDisconnectDeletesEmptyRoom(t *testing.T) {
+	room := &Room{
+		ID:           "room1",
+		Participants: map[string]*Participant{"alice": {Username: "alice"}},
+	}
+
+	roomsMu.Lock()
+	rooms["room1"] = room
+	userRoom["alice"] = "room1"
+	roomsMu.Unlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, "room1")
+		delete(userRoom, "alice")
+		roomsMu.Unlock()
+	})
+
+	leaveRoomOnDisconnect(context.Background(), "alice")
+
+	roomsMu.RLock()
+	_, exists := rooms["room1"]
+	roomsMu.RUnlock()
+	if exists {
+		t.Error("room should have been deleted once empty")
+	}
+}
+
+func TestHandleJoinAndLeaveRoomHTTP(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/room", nil)
+	req.Header.Set("X-Username", "alice")
+	handleCreateRoom(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("handleCreateRoom status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	roomsMu.RLock()
+	var roomID string
+	for id := range rooms {
+		roomID = id
+	}
+	roomsMu.RUnlock()
+	t.Cleanup(func() {
+		roomsMu.Lock()
+		delete(rooms, roomID)
+		delete(userRoom, "alice")
+		delete(userRoom, "bob")
+		roomsMu.Unlock()
+	})
+
+	joinRec := httptest.NewRecorder()
+	joinReq := httptest.NewRequest(http.MethodPost, "/api/room/"+roomID+"/join", nil)
+	joinReq.Header.Set("X-Username", "bob")
+	handleJoinRoom(joinRec, joinReq, roomID)
+	if joinRec.Code != http.StatusOK {
+		t.Fatalf("handleJoinRoom status = %d, body = %s", joinRec.Code, joinRec.Body.String())
+	}
+
+	roomsMu.RLock()
+	roomIDForBob, inRoom := userRoom["bob"]
+	roomsMu.RUnlock()
+	if !inRoom || roomIDForBob != roomID {
+		t.Fatalf("bob not recorded as a member of %s", roomID)
+	}
+
+	leaveRec := httptest.NewRecorder()
+	leaveReq := httptest.NewRequest(http.MethodPost, "/api/room/"+roomID+"/leave", nil)
+	leaveReq.Header.Set("X-Username", "bob")
+	handleLeaveRoom(leaveRec, leaveReq, roomID)
+	if leaveRec.Code != http.StatusNoContent {
+		t.Fatalf("handleLeaveRoom status = %d, body = %s", leaveRec.Code, leaveRec.Body.String())
+	}
+
+	roomsMu.RLock()
+	_, stillInRoom := userRoom["bob"]
+	roomsMu.RUnlock()
+	if stillInRoom {
+		t.Error("bob should have been removed from userRoom after leaving")
+	}
+}
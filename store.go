@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// Notifier delivers an event to a user's WebSocket connection, wherever it
+// happens to be. LocalNotifier only knows about connections on this
+// instance; RedisNotifier additionally fans a message out over Pub/Sub so
+// the node holding the target's connection can deliver it too - this is
+// what lets a signal received on one instance reach a peer connected to
+// another one behind the load balancer.
+type Notifier interface {
+	Notify(ctx context.Context, username, event string, data interface{}) error
+}
+
+// LocalNotifier writes directly to a WebSocket connection on this instance,
+// if the target user has one. This is the original single-instance
+// behavior and remains the default.
+type LocalNotifier struct{}
+
+func (LocalNotifier) Notify(ctx context.Context, username, event string, data interface{}) error {
+	mu.RLock()
+	client, ok := wsConnections[username]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	client.Send(map[string]interface{}{"event": event, "data": data})
+	return nil
+}
+
+const fanoutChannel = "webrtc-signal-fanout"
+
+type fanoutMessage struct {
+	Username string      `json:"username"`
+	Event    string      `json:"event"`
+	Data     interface{} `json:"data"`
+}
+
+// RedisNotifier delivers locally when possible and otherwise publishes to
+// fanoutChannel so every instance subscribed to it can deliver to the
+// target if it happens to be connected there.
+type RedisNotifier struct {
+	client *redis.Client
+	local  LocalNotifier
+}
+
+func NewRedisNotifier(client *redis.Client) *RedisNotifier {
+	return &RedisNotifier{client: client}
+}
+
+func (n *RedisNotifier) Notify(ctx context.Context, username, event string, data interface{}) error {
+	mu.RLock()
+	_, connectedLocally := wsConnections[username]
+	mu.RUnlock()
+	if connectedLocally {
+		return n.local.Notify(ctx, username, event, data)
+	}
+
+	raw, err := json.Marshal(fanoutMessage{Username: username, Event: event, Data: data})
+	if err != nil {
+		return err
+	}
+	return n.client.Publish(ctx, fanoutChannel, raw).Err()
+}
+
+// StartSubscriber listens for fan-out messages published by other instances
+// and delivers them to any matching connection held locally. It runs until
+// ctx is cancelled.
+func (n *RedisNotifier) StartSubscriber(ctx context.Context) {
+	sub := n.client.Subscribe(ctx, fanoutChannel)
+	ch := sub.Channel()
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var fm fanoutMessage
+				if err := json.Unmarshal([]byte(msg.Payload), &fm); err != nil {
+					logger.Error("fanout: invalid message", "error", err)
+					continue
+				}
+				if err := n.local.Notify(ctx, fm.Username, fm.Event, fm.Data); err != nil {
+					logger.Error("fanout: delivery error", "username", fm.Username, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// SessionStore abstracts where Session state lives. The in-memory
+// implementation backs a single instance; Redis and Postgres let the
+// signaling server run as a horizontally scaled fleet behind a load
+// balancer, since any instance can look up a session owned by a peer
+// connected to another one.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	UpdateSession(ctx context.Context, session *Session) error
+	DeleteSession(ctx context.Context, sessionID string) error
+
+	SetUserSession(ctx context.Context, username, sessionID string) error
+	GetUserSession(ctx context.Context, username string) (string, error)
+	DeleteUserSession(ctx context.Context, username string) error
+
+	ListActiveSessions(ctx context.Context) ([]*Session, error)
+
+	// SetUserSessionIfAbsent atomically sets username's session pointer to
+	// sessionID only if username has none yet, returning false (no error)
+	// if one already existed. It is the compare-and-swap primitive that
+	// makes the busy-check in handleCreateSession race-safe: a plain
+	// GetUserSession-then-SetUserSession sequence lets two concurrent
+	// callers both observe "free" before either writes.
+	SetUserSessionIfAbsent(ctx context.Context, username, sessionID string) (bool, error)
+
+	// UpdateSessionIfStatus atomically persists session only if the
+	// currently stored session's Status still equals expectedStatus,
+	// returning false (no error) if it has already moved on - e.g. a
+	// concurrent accept/decline/cancel/disconnect beat this caller to it.
+	UpdateSessionIfStatus(ctx context.Context, session *Session, expectedStatus string) (bool, error)
+}
+
+// StoreBackend selects a SessionStore implementation via config.
+type StoreBackend string
+
+const (
+	StoreBackendMemory   StoreBackend = "memory"
+	StoreBackendRedis    StoreBackend = "redis"
+	StoreBackendPostgres StoreBackend = "postgres"
+)
+
+// NewSessionStore constructs the SessionStore selected by backend. dsn is
+// the Redis address or Postgres connection string and is ignored for the
+// memory backend.
+func NewSessionStore(ctx context.Context, backend StoreBackend, dsn string) (SessionStore, error) {
+	switch backend {
+	case StoreBackendMemory, "":
+		return NewMemorySessionStore(), nil
+	case StoreBackendRedis:
+		return NewRedisSessionStore(dsn)
+	case StoreBackendPostgres:
+		return NewPostgresSessionStore(ctx, dsn)
+	default:
+		return nil, fmt.Errorf("unknown session store backend: %s", backend)
+	}
+}
+
+// ErrSessionNotFound is returned by SessionStore implementations when a
+// sessionId or username has no associated session.
+var ErrSessionNotFound = fmt.Errorf("session not found")
+
+// MemorySessionStore is the default, single-instance SessionStore backed by
+// the same maps the server used before stores existed.
+type MemorySessionStore struct {
+	mu           sync.RWMutex
+	sessions     map[string]*Session
+	userSessions map[string]string
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:     make(map[string]*Session),
+		userSessions: make(map[string]string),
+	}
+}
+
+func (s *MemorySessionStore) CreateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *session
+	s.sessions[session.SessionID] = &cp
+	return nil
+}
+
+// GetSession returns a copy of the stored session, like the Redis and
+// Postgres implementations effectively do via marshal/unmarshal - callers
+// mutating the result (e.g. changing Status) must call UpdateSession to
+// persist it, rather than racing other goroutines on the store's own copy.
+func (s *MemorySessionStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	cp := *session
+	return &cp, nil
+}
+
+func (s *MemorySessionStore) UpdateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[session.SessionID]; !ok {
+		return ErrSessionNotFound
+	}
+	cp := *session
+	s.sessions[session.SessionID] = &cp
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *MemorySessionStore) SetUserSession(ctx context.Context, username, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userSessions[username] = sessionID
+	return nil
+}
+
+func (s *MemorySessionStore) SetUserSessionIfAbsent(ctx context.Context, username, sessionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.userSessions[username]; exists {
+		return false, nil
+	}
+	s.userSessions[username] = sessionID
+	return true, nil
+}
+
+func (s *MemorySessionStore) UpdateSessionIfStatus(ctx context.Context, session *Session, expectedStatus string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.sessions[session.SessionID]
+	if !ok {
+		return false, ErrSessionNotFound
+	}
+	if existing.Status != expectedStatus {
+		return false, nil
+	}
+	cp := *session
+	s.sessions[session.SessionID] = &cp
+	return true, nil
+}
+
+func (s *MemorySessionStore) GetUserSession(ctx context.Context, username string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessionID, ok := s.userSessions[username]
+	if !ok {
+		return "", ErrSessionNotFound
+	}
+	return sessionID, nil
+}
+
+func (s *MemorySessionStore) DeleteUserSession(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.userSessions, username)
+	return nil
+}
+
+func (s *MemorySessionStore) ListActiveSessions(ctx context.Context) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	active := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		if session.Status == "active" {
+			cp := *session
+			active = append(active, &cp)
+		}
+	}
+	return active, nil
+}
+
+// RedisSessionStore stores sessions as JSON blobs under "session:<id>" and
+// the username->session pointer under "user-session:<username>", so any
+// node in the fleet can resolve either side of a call.
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(addr string) (*RedisSessionStore, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	return &RedisSessionStore{client: client}, nil
+}
+
+func sessionKey(sessionID string) string    { return "session:" + sessionID }
+func userSessionKey(username string) string { return "user-session:" + username }
+
+func (s *RedisSessionStore) CreateSession(ctx context.Context, session *Session) error {
+	return s.UpdateSession(ctx, session)
+}
+
+func (s *RedisSessionStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	raw, err := s.client.Get(ctx, sessionKey(sessionID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *RedisSessionStore) UpdateSession(ctx context.Context, session *Session) error {
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, sessionKey(session.SessionID), raw, 0).Err()
+}
+
+func (s *RedisSessionStore) DeleteSession(ctx context.Context, sessionID string) error {
+	return s.client.Del(ctx, sessionKey(sessionID)).Err()
+}
+
+func (s *RedisSessionStore) SetUserSession(ctx context.Context, username, sessionID string) error {
+	return s.client.Set(ctx, userSessionKey(username), sessionID, 0).Err()
+}
+
+func (s *RedisSessionStore) GetUserSession(ctx context.Context, username string) (string, error) {
+	sessionID, err := s.client.Get(ctx, userSessionKey(username)).Result()
+	if err == redis.Nil {
+		return "", ErrSessionNotFound
+	}
+	return sessionID, err
+}
+
+func (s *RedisSessionStore) DeleteUserSession(ctx context.Context, username string) error {
+	return s.client.Del(ctx, userSessionKey(username)).Err()
+}
+
+func (s *RedisSessionStore) SetUserSessionIfAbsent(ctx context.Context, username, sessionID string) (bool, error) {
+	return s.client.SetNX(ctx, userSessionKey(username), sessionID, 0).Result()
+}
+
+// updateSessionIfStatusScript is Redis's compare-and-swap primitive: GET,
+// compare, SET happen atomically inside the server rather than as three
+// round-trips a concurrent writer could interleave with.
+var updateSessionIfStatusScript = redis.NewScript(`
+local raw = redis.call('GET', KEYS[1])
+if not raw then
+	return -1
+end
+local current = cjson.decode(raw)
+if current.status ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1
+`)
+
+func (s *RedisSessionStore) UpdateSessionIfStatus(ctx context.Context, session *Session, expectedStatus string) (bool, error) {
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+	result, err := updateSessionIfStatusScript.Run(ctx, s.client, []string{sessionKey(session.SessionID)}, expectedStatus, raw).Int()
+	if err != nil {
+		return false, err
+	}
+	if result == -1 {
+		return false, ErrSessionNotFound
+	}
+	return result == 1, nil
+}
+
+func (s *RedisSessionStore) ListActiveSessions(ctx context.Context) ([]*Session, error) {
+	var active []*Session
+	iter := s.client.Scan(ctx, 0, "session:*", 0).Iterator()
+	for iter.Next(ctx) {
+		raw, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(raw, &session); err != nil {
+			continue
+		}
+		if session.Status == "active" {
+			active = append(active, &session)
+		}
+	}
+	return active, iter.Err()
+}
+
+// PostgresSessionStore persists sessions in a `sessions` table via pgx.
+// Schema (created out of band by migrations):
+//
+//	CREATE TABLE sessions (
+//	    session_id TEXT PRIMARY KEY,
+//	    caller TEXT NOT NULL,
+//	    target TEXT NOT NULL,
+//	    status TEXT NOT NULL,
+//	    type TEXT NOT NULL,
+//	    created_at TIMESTAMPTZ NOT NULL
+//	);
+//	CREATE TABLE user_sessions (
+//	    username TEXT PRIMARY KEY,
+//	    session_id TEXT NOT NULL
+//	);
+type PostgresSessionStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresSessionStore(ctx context.Context, dsn string) (*PostgresSessionStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	return &PostgresSessionStore{pool: pool}, nil
+}
+
+func (s *PostgresSessionStore) CreateSession(ctx context.Context, session *Session) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO sessions (session_id, caller, target, status, type, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		session.SessionID, session.Caller, session.Target, session.Status, session.Type, session.CreatedAt)
+	return err
+}
+
+func (s *PostgresSessionStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	var session Session
+	err := s.pool.QueryRow(ctx,
+		`SELECT session_id, caller, target, status, type, created_at FROM sessions WHERE session_id = $1`,
+		sessionID,
+	).Scan(&session.SessionID, &session.Caller, &session.Target, &session.Status, &session.Type, &session.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *PostgresSessionStore) UpdateSession(ctx context.Context, session *Session) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE sessions SET caller = $2, target = $3, status = $4, type = $5, created_at = $6 WHERE session_id = $1`,
+		session.SessionID, session.Caller, session.Target, session.Status, session.Type, session.CreatedAt)
+	return err
+}
+
+func (s *PostgresSessionStore) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE session_id = $1`, sessionID)
+	return err
+}
+
+func (s *PostgresSessionStore) SetUserSession(ctx context.Context, username, sessionID string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO user_sessions (username, session_id) VALUES ($1, $2)
+		 ON CONFLICT (username) DO UPDATE SET session_id = EXCLUDED.session_id`,
+		username, sessionID)
+	return err
+}
+
+func (s *PostgresSessionStore) GetUserSession(ctx context.Context, username string) (string, error) {
+	var sessionID string
+	err := s.pool.QueryRow(ctx, `SELECT session_id FROM user_sessions WHERE username = $1`, username).Scan(&sessionID)
+	if err == pgx.ErrNoRows {
+		return "", ErrSessionNotFound
+	}
+	return sessionID, err
+}
+
+func (s *PostgresSessionStore) DeleteUserSession(ctx context.Context, username string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM user_sessions WHERE username = $1`, username)
+	return err
+}
+
+func (s *PostgresSessionStore) SetUserSessionIfAbsent(ctx context.Context, username, sessionID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO user_sessions (username, session_id) VALUES ($1, $2) ON CONFLICT (username) DO NOTHING`,
+		username, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresSessionStore) UpdateSessionIfStatus(ctx context.Context, session *Session, expectedStatus string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE sessions SET caller = $2, target = $3, status = $4, type = $5, created_at = $6
+		 WHERE session_id = $1 AND status = $7`,
+		session.SessionID, session.Caller, session.Target, session.Status, session.Type, session.CreatedAt, expectedStatus)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresSessionStore) ListActiveSessions(ctx context.Context) ([]*Session, error) {
+	rows, err := s.pool.Query(ctx, `SELECT session_id, caller, target, status, type, created_at FROM sessions WHERE status = 'active'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var active []*Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.SessionID, &session.Caller, &session.Target, &session.Status, &session.Type, &session.CreatedAt); err != nil {
+			return nil, err
+		}
+		active = append(active, &session)
+	}
+	return active, rows.Err()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemorySessionStoreGetSessionReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	session := &Session{SessionID: "s1", Caller: "alice", Target: "bob", Status: "pending"}
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	got, err := s.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	got.Status = "active"
+
+	again, err := s.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if again.Status != "pending" {
+		t.Errorf("mutating a GetSession result changed the store's copy: got status %q, want %q", again.Status, "pending")
+	}
+}
+
+func TestMemorySessionStoreUpdateSessionPersists(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	session := &Session{SessionID: "s1", Status: "pending"}
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	updated, err := s.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	updated.Status = "active"
+	if err := s.UpdateSession(ctx, updated); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+
+	got, err := s.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Status != "active" {
+		t.Errorf("status = %q, want %q", got.Status, "active")
+	}
+}
+
+// TestMemorySessionStoreConcurrentGetUpdate reproduces the shape of
+// handleAcceptSession racing handleCancelSession on the same session: two
+// goroutines each Get, mutate their own copy, then Update. Run with
+// `go test -race` to confirm there's no data race on the store's internal
+// *Session, now that Get/Update copy rather than share it.
+func TestMemorySessionStoreConcurrentGetUpdate(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	session := &Session{SessionID: "s1", Status: "pending"}
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	statuses := []string{"active", "cancelled"}
+	for _, status := range statuses {
+		wg.Add(1)
+		go func(status string) {
+			defer wg.Done()
+			got, err := s.GetSession(ctx, "s1")
+			if err != nil {
+				t.Errorf("GetSession: %v", err)
+				return
+			}
+			got.Status = status
+			if err := s.UpdateSession(ctx, got); err != nil {
+				t.Errorf("UpdateSession: %v", err)
+			}
+		}(status)
+	}
+	wg.Wait()
+}
+
+func TestMemorySessionStoreSetUserSessionIfAbsent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	set, err := s.SetUserSessionIfAbsent(ctx, "alice", "s1")
+	if err != nil {
+		t.Fatalf("SetUserSessionIfAbsent: %v", err)
+	}
+	if !set {
+		t.Fatal("expected first SetUserSessionIfAbsent to succeed")
+	}
+
+	set, err = s.SetUserSessionIfAbsent(ctx, "alice", "s2")
+	if err != nil {
+		t.Fatalf("SetUserSessionIfAbsent: %v", err)
+	}
+	if set {
+		t.Fatal("expected second SetUserSessionIfAbsent to fail, alice already has a session")
+	}
+
+	sessionID, err := s.GetUserSession(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserSession: %v", err)
+	}
+	if sessionID != "s1" {
+		t.Errorf("sessionID = %q, want %q - it should not have been overwritten", sessionID, "s1")
+	}
+}
+
+// TestMemorySessionStoreSetUserSessionIfAbsentConcurrent reproduces the
+// handleCreateSession busy-check race: two goroutines race to claim the same
+// username with different session IDs. Exactly one must win.
+func TestMemorySessionStoreSetUserSessionIfAbsentConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	results := make([]bool, 2)
+	var wg sync.WaitGroup
+	for i, sessionID := range []string{"s1", "s2"} {
+		wg.Add(1)
+		go func(i int, sessionID string) {
+			defer wg.Done()
+			set, err := s.SetUserSessionIfAbsent(ctx, "bob", sessionID)
+			if err != nil {
+				t.Errorf("SetUserSessionIfAbsent: %v", err)
+				return
+			}
+			results[i] = set
+		}(i, sessionID)
+	}
+	wg.Wait()
+
+	if results[0] == results[1] {
+		t.Fatalf("expected exactly one caller to win the busy-check race, got %v", results)
+	}
+}
+
+func TestMemorySessionStoreUpdateSessionIfStatus(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionStore()
+
+	session := &Session{SessionID: "s1", Status: "pending"}
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	active := &Session{SessionID: "s1", Status: "active"}
+	ok, err := s.UpdateSessionIfStatus(ctx, active, "active")
+	if err != nil {
+		t.Fatalf("UpdateSessionIfStatus: %v", err)
+	}
+	if ok {
+		t.Fatal("expected UpdateSessionIfStatus to fail, stored status is still pending")
+	}
+
+	ok, err = s.UpdateSessionIfStatus(ctx, active, "pending")
+	if err != nil {
+		t.Fatalf("UpdateSessionIfStatus: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected UpdateSessionIfStatus to succeed, stored status matched expected")
+	}
+
+	got, err := s.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Status != "active" {
+		t.Errorf("status = %q, want %q", got.Status, "active")
+	}
+
+	// A second caller racing in with the same stale expectation must lose.
+	cancelled := &Session{SessionID: "s1", Status: "cancelled"}
+	ok, err = s.UpdateSessionIfStatus(ctx, cancelled, "pending")
+	if err != nil {
+		t.Fatalf("UpdateSessionIfStatus: %v", err)
+	}
+	if ok {
+		t.Fatal("expected UpdateSessionIfStatus to fail, status already moved to active")
+	}
+}
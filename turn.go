@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TURNConfig holds the shared secret and server list used to mint
+// short-lived TURN credentials, following the turn-rest-api scheme used by
+// coturn's use-auth-secret mode.
+type TURNConfig struct {
+	SharedSecret string
+	Realm        string
+	TTL          time.Duration
+	URLs         []string
+}
+
+// loadTURNConfig reads TURN settings from the environment:
+//
+//	TURN_SECRET   shared secret used to sign credentials (required)
+//	TURN_REALM    realm advertised to clients (default "webrtc-go-server")
+//	TURN_TTL_SECONDS  credential lifetime in seconds (default 3600)
+//	TURN_URLS     comma-separated stun:/turn:/turns: URLs
+func loadTURNConfig() TURNConfig {
+	ttl := 3600
+	if v := os.Getenv("TURN_TTL_SECONDS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			ttl = parsed
+		}
+	}
+
+	realm := os.Getenv("TURN_REALM")
+	if realm == "" {
+		realm = "webrtc-go-server"
+	}
+
+	var urls []string
+	if v := os.Getenv("TURN_URLS"); v != "" {
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+	}
+
+	return TURNConfig{
+		SharedSecret: os.Getenv("TURN_SECRET"),
+		Realm:        realm,
+		TTL:          time.Duration(ttl) * time.Second,
+		URLs:         urls,
+	}
+}
+
+type TURNCredentialsResponse struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	TTL      int      `json:"ttl"`
+	URLs     []string `json:"urls"`
+}
+
+// turnCredential computes the turn-rest-api username/password pair:
+// username = "<unix-expiry>:<user>", password = base64(HMAC-SHA1(username, secret)).
+func turnCredential(cfg TURNConfig, user string, now time.Time) (username, password string) {
+	expiry := now.Add(cfg.TTL).Unix()
+	username = fmt.Sprintf("%d:%s", expiry, user)
+
+	mac := hmac.New(sha1.New, []byte(cfg.SharedSecret))
+	mac.Write([]byte(username))
+	password = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return username, password
+}
+
+// GET /api/turn-credentials
+func handleTURNCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if turnConfig.SharedSecret == "" {
+		http.Error(w, "TURN not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+
+	credUsername, credPassword := turnCredential(turnConfig, username, time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TURNCredentialsResponse{
+		Username: credUsername,
+		Password: credPassword,
+		TTL:      int(turnConfig.TTL.Seconds()),
+		URLs:     turnConfig.URLs,
+	})
+}
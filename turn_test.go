@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTurnCredentialMatchesTurnRestApiScheme(t *testing.T) {
+	cfg := TURNConfig{SharedSecret: "shhh", TTL: time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	username, password := turnCredential(cfg, "alice", now)
+
+	wantUsername := fmt.Sprintf("%d:alice", now.Add(cfg.TTL).Unix())
+	if username != wantUsername {
+		t.Errorf("username = %q, want %q", username, wantUsername)
+	}
+
+	mac := hmac.New(sha1.New, []byte(cfg.SharedSecret))
+	mac.Write([]byte(wantUsername))
+	wantPassword := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if password != wantPassword {
+		t.Errorf("password = %q, want %q", password, wantPassword)
+	}
+}
+
+func TestTurnCredentialExpiryTracksTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	shortCfg := TURNConfig{SharedSecret: "shhh", TTL: time.Minute}
+	longCfg := TURNConfig{SharedSecret: "shhh", TTL: time.Hour}
+
+	shortUsername, _ := turnCredential(shortCfg, "alice", now)
+	longUsername, _ := turnCredential(longCfg, "alice", now)
+
+	if shortUsername == longUsername {
+		t.Errorf("expected different expiries for different TTLs, both got %q", shortUsername)
+	}
+}
+
+func TestTurnCredentialDifferentSecretsDifferentPasswords(t *testing.T) {
+	cfg1 := TURNConfig{SharedSecret: "secret-one", TTL: time.Hour}
+	cfg2 := TURNConfig{SharedSecret: "secret-two", TTL: time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	_, password1 := turnCredential(cfg1, "alice", now)
+	_, password2 := turnCredential(cfg2, "alice", now)
+
+	if password1 == password2 {
+		t.Error("expected different shared secrets to produce different passwords")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeWait bounds how long a single write (event, ping, or close frame) may
+// block the connection's writePump before it's considered dead.
+const writeWait = 10 * time.Second
+
+// WSConfig controls per-connection keepalive and backpressure behavior.
+type WSConfig struct {
+	PingTimeout      time.Duration
+	ClientEventQueue int
+}
+
+// loadWSConfig reads WebSocket tuning from the environment:
+//
+//	WS_PING_TIMEOUT_SECONDS  seconds of silence tolerated before a client is
+//	                         considered dead; pings are sent at 9/10 of this
+//	                         interval (default 30)
+//	WS_CLIENT_EVENT_QUEUE    buffered outbound events allowed per client
+//	                         before it's dropped as a slow consumer (default 16)
+func loadWSConfig() WSConfig {
+	pingTimeout := 30
+	if v := os.Getenv("WS_PING_TIMEOUT_SECONDS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			pingTimeout = parsed
+		}
+	}
+
+	queueSize := 16
+	if v := os.Getenv("WS_CLIENT_EVENT_QUEUE"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			queueSize = parsed
+		}
+	}
+
+	return WSConfig{
+		PingTimeout:      time.Duration(pingTimeout) * time.Second,
+		ClientEventQueue: queueSize,
+	}
+}
+
+// Client owns one upgraded WebSocket connection. writePump is the only
+// goroutine that ever writes to conn, so concurrent Notify/broadcast calls
+// can enqueue freely without racing on the same *websocket.Conn - that race,
+// via unbounded `go func(){ conn.WriteJSON(...) }()` calls, is what this
+// replaces.
+type Client struct {
+	username string
+	conn     *websocket.Conn
+	cfg      WSConfig
+
+	send        chan []byte
+	closeOnce   sync.Once
+	closed      chan struct{}
+	closeCode   int
+	closeReason string
+}
+
+// NewClient wraps an upgraded connection. Callers still need to register it
+// in wsConnections and start writePump.
+func NewClient(username string, conn *websocket.Conn, cfg WSConfig) *Client {
+	return &Client{
+		username: username,
+		conn:     conn,
+		cfg:      cfg,
+		send:     make(chan []byte, cfg.ClientEventQueue),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Send marshals payload and enqueues it for delivery. If the outbound queue
+// is already full - a slow or stalled consumer - the message is dropped and
+// the connection is torn down rather than let one bad reader grow memory
+// without bound or block the caller (which may be holding mu).
+func (c *Client) Send(payload interface{}) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("websocket: failed to marshal outbound payload", "username", c.username, "error", err)
+		return
+	}
+
+	select {
+	case c.send <- raw:
+	default:
+		websocketSlowConsumerDisconnectsTotal.Inc()
+		logger.Info("websocket: dropping slow consumer", "username", c.username, "queue_size", c.cfg.ClientEventQueue)
+		c.Close(websocket.ClosePolicyViolation, "slow consumer")
+	}
+}
+
+// initReadDeadline arms the initial read deadline and pong handler. The
+// deadline is pushed out by PingTimeout every time a pong arrives, so a
+// client that stops responding to pings is dropped within one PingTimeout.
+func (c *Client) initReadDeadline() {
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.PingTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(c.cfg.PingTimeout))
+		return nil
+	})
+}
+
+// writePump serializes queued events and periodic pings onto conn. It exits
+// once Close is called, draining any already-queued events first so a
+// graceful shutdown doesn't silently drop messages that were in flight.
+func (c *Client) writePump() {
+	pingPeriod := (c.cfg.PingTimeout * 9) / 10
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case raw := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, raw); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-c.closed:
+			for {
+				select {
+				case raw := <-c.send:
+					c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+					c.conn.WriteMessage(websocket.TextMessage, raw)
+				default:
+					c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+					c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close requests a close frame with the given code/reason and signals
+// writePump to drain and exit. Safe to call more than once and from any
+// goroutine - a slow-consumer drop and a server shutdown may race to close
+// the same client - but the actual conn write still only ever happens on
+// writePump, since conn.Close() only ever closes from there too.
+func (c *Client) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.closed)
+	})
+}
+
+// wsWG tracks in-flight WebSocket connections so graceful shutdown can wait
+// for each one's writePump to drain and its disconnect cleanup to run.
+var wsWG sync.WaitGroup
+
+// closeAllClients sends every connected client a going_away close frame, so
+// clients see a clean disconnect instead of the TCP connection just
+// dropping when the server exits.
+func closeAllClients() {
+	mu.RLock()
+	clients := make([]*Client, 0, len(wsConnections))
+	for _, c := range wsConnections {
+		clients = append(clients, c)
+	}
+	mu.RUnlock()
+
+	for _, c := range clients {
+		c.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// waitForDrain blocks until every connection registered via wsWG has
+// finished its cleanup, or timeout elapses - whichever comes first, so a
+// stuck connection can't hang shutdown indefinitely.
+func waitForDrain(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Info("shutdown: timed out waiting for websocket connections to drain")
+	}
+}